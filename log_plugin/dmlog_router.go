@@ -0,0 +1,154 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logplugin
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// DMLogHandler processes the fields of a single DMLOG line for a given verb.
+// `fields` are the whitespace-separated tokens following the verb, in order.
+type DMLogHandler func(fields []string) error
+
+// DMLogRouter sits in front of ToZapLogPlugin (or any other LogPlugin) and
+// turns DMLOG lines into a first-class telemetry stream: it parses the verb
+// (the first token after the "DMLOG " prefix) and dispatches the remaining
+// fields to handlers registered for that verb. Lines that are not DMLOG
+// lines, or whose verb has no registered handler, fall through unchanged to
+// the wrapped plugin.
+type DMLogRouter struct {
+	next     LogPlugin
+	logger   *zap.Logger
+	handlers map[string][]DMLogHandler
+}
+
+// LogPlugin is the minimal contract a log consumer must satisfy to be
+// wrapped by DMLogRouter (and, conversely, to be used as one itself).
+type LogPlugin interface {
+	LogLine(in string)
+	Close(err error)
+}
+
+// NewDMLogRouter creates a router that dispatches recognized DMLOG verbs to
+// registered handlers and forwards every line, recognized or not, to next.
+func NewDMLogRouter(next LogPlugin, logger *zap.Logger) *DMLogRouter {
+	return &DMLogRouter{
+		next:     next,
+		logger:   logger,
+		handlers: make(map[string][]DMLogHandler),
+	}
+}
+
+// RegisterHandler adds h to the list of handlers invoked whenever a DMLOG
+// line for verb is seen. Multiple handlers may be registered for the same
+// verb; they run in registration order and a failing handler does not
+// prevent the others from running.
+func (r *DMLogRouter) RegisterHandler(verb string, h DMLogHandler) {
+	r.handlers[verb] = append(r.handlers[verb], h)
+}
+
+// LogLine implements LogPlugin. It always forwards in to the wrapped plugin
+// so existing pass-through behavior (level detection, debugDeepMind gating,
+// etc.) is preserved; recognized DMLOG verbs are additionally dispatched to
+// their registered handlers.
+func (r *DMLogRouter) LogLine(in string) {
+	if strings.HasPrefix(in, "DMLOG ") {
+		fields := strings.Fields(strings.TrimPrefix(in, "DMLOG "))
+		if len(fields) > 0 {
+			verb := fields[0]
+			for _, h := range r.handlers[verb] {
+				if err := h(fields[1:]); err != nil {
+					r.logger.Warn("dmlog handler failed", zap.String("verb", verb), zap.Error(err))
+				}
+			}
+		}
+	}
+
+	r.next.LogLine(in)
+}
+
+// Close implements LogPlugin by forwarding to the wrapped plugin.
+func (r *DMLogRouter) Close(err error) {
+	r.next.Close(err)
+}
+
+// NewDMLogVerbCounterHandler returns a DMLogHandler that increments counter,
+// labelled by verb, each time it runs. Register it under the same verb it
+// counts, for example:
+//
+//	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dmlog_lines_total"}, []string{"verb"})
+//	router.RegisterHandler("FEAT", NewDMLogVerbCounterHandler("FEAT", counter))
+func NewDMLogVerbCounterHandler(verb string, counter *prometheus.CounterVec) DMLogHandler {
+	return func(_ []string) error {
+		counter.WithLabelValues(verb).Inc()
+		return nil
+	}
+}
+
+// DMLogFieldSchema names the fields of a DMLOG verb, in the order they appear
+// on the line, so a structured zap event can be emitted with named rather
+// than positional fields. It is registered by the chain-specific mindreader
+// plugin, which knows the wire format for each verb it cares about.
+type DMLogFieldSchema struct {
+	Verb       string
+	FieldNames []string
+	EventLevel func(fields []string) bool // optional; returning true logs at Info instead of Debug
+}
+
+// NewDMLogStructuredEventHandler returns a DMLogHandler that logs a structured
+// zap event for the verb described by schema. Fields beyond len(schema.FieldNames)
+// are ignored; fields missing from the line are simply omitted from the event.
+func NewDMLogStructuredEventHandler(logger *zap.Logger, schema DMLogFieldSchema) DMLogHandler {
+	return func(fields []string) error {
+		zapFields := make([]zap.Field, 0, len(schema.FieldNames))
+		for i, name := range schema.FieldNames {
+			if i >= len(fields) {
+				break
+			}
+			zapFields = append(zapFields, zap.String(name, fields[i]))
+		}
+
+		level := zap.DebugLevel
+		if schema.EventLevel != nil && schema.EventLevel(fields) {
+			level = zap.InfoLevel
+		}
+
+		logger.Check(level, schema.Verb).Write(zapFields...)
+		return nil
+	}
+}
+
+// NewDMLogSamplingHandler wraps h so it only runs on every Nth invocation for
+// a given verb, dropping the rest. This is meant to tame log volume during a
+// flood of a single verb without losing the handler's effect entirely (e.g.
+// the counter keeps counting every line via its own handler registration,
+// while a structured event handler can be sampled down separately).
+func NewDMLogSamplingHandler(n int, h DMLogHandler) DMLogHandler {
+	if n <= 1 {
+		return h
+	}
+
+	var count int
+	return func(fields []string) error {
+		count++
+		if count%n != 0 {
+			return nil
+		}
+		return h(fields)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logplugin
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+type recordingLogPlugin struct {
+	lines  []string
+	closed bool
+}
+
+func (r *recordingLogPlugin) LogLine(in string) { r.lines = append(r.lines, in) }
+func (r *recordingLogPlugin) Close(_ error)     { r.closed = true }
+
+func TestDMLogRouterForwardsEveryLine(t *testing.T) {
+	next := &recordingLogPlugin{}
+	router := NewDMLogRouter(next, zap.NewNop())
+
+	router.LogLine("DMLOG FEAT foo bar")
+	router.LogLine("info something else")
+
+	if len(next.lines) != 2 {
+		t.Fatalf("expected both lines forwarded, got %v", next.lines)
+	}
+
+	router.Close(nil)
+	if !next.closed {
+		t.Fatal("expected Close to forward to the wrapped plugin")
+	}
+}
+
+func TestDMLogRouterDispatchesRegisteredVerb(t *testing.T) {
+	next := &recordingLogPlugin{}
+	router := NewDMLogRouter(next, zap.NewNop())
+
+	var gotFields []string
+	var calls int
+	router.RegisterHandler("FEAT", func(fields []string) error {
+		calls++
+		gotFields = fields
+		return nil
+	})
+
+	router.LogLine("DMLOG FEAT foo bar")
+	router.LogLine("DMLOG OTHER baz") // unregistered verb, handler must not run
+
+	if calls != 1 {
+		t.Fatalf("expected handler to run exactly once, ran %d times", calls)
+	}
+	if len(gotFields) != 2 || gotFields[0] != "foo" || gotFields[1] != "bar" {
+		t.Fatalf("expected fields [foo bar], got %v", gotFields)
+	}
+}
+
+func TestNewDMLogVerbCounterHandlerIncrementsByVerb(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_dmlog_lines_total"}, []string{"verb"})
+	router := NewDMLogRouter(&recordingLogPlugin{}, zap.NewNop())
+	router.RegisterHandler("FEAT", NewDMLogVerbCounterHandler("FEAT", counter))
+
+	router.LogLine("DMLOG FEAT a")
+	router.LogLine("DMLOG FEAT b")
+
+	var m dto.Metric
+	if err := counter.WithLabelValues("FEAT").Write(&m); err != nil {
+		t.Fatalf("reading counter: %v", err)
+	}
+	if got := m.GetCounter().GetValue(); got != 2 {
+		t.Fatalf("expected counter at 2, got %v", got)
+	}
+}
+
+func TestNewDMLogSamplingHandlerDropsBetweenEveryNth(t *testing.T) {
+	var calls int
+	sampled := NewDMLogSamplingHandler(3, func(_ []string) error {
+		calls++
+		return nil
+	})
+
+	for i := 0; i < 9; i++ {
+		if err := sampled(nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected exactly 3 calls out of 9 at sampling rate 3, got %d", calls)
+	}
+}
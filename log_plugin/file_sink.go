@@ -0,0 +1,87 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logplugin
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// FileRotateConfig controls how the rotating file sink installed by
+// ToZapLogPluginWithFileSink rolls its output files on disk.
+type FileRotateConfig struct {
+	MaxSizeMB  int  // maximum size in megabytes of a log file before it gets rotated
+	MaxAgeDays int  // maximum number of days to retain old log files, 0 means keep forever
+	MaxBackups int  // maximum number of old log files to retain, 0 means keep all
+	Compress   bool // whether rotated files should be gzip compressed
+}
+
+// ToZapLogPluginFormat selects the encoding used for the file sink installed
+// by ToZapLogPluginWithFileSink. It has no effect on the in-process logger
+// passed to NewToZapLogPlugin, which keeps whatever encoding it was configured
+// with.
+type ToZapLogPluginFormat int
+
+const (
+	// FormatText encodes file sink lines using zap's console encoder.
+	FormatText ToZapLogPluginFormat = iota
+	// FormatJSON encodes file sink lines using zap's JSON encoder.
+	FormatJSON
+)
+
+// ToZapLogPluginWithFileSink directs the node's log lines into a rotating
+// file on disk, through a logger dedicated to that file core. This is kept
+// separate from the in-process logger given to NewToZapLogPlugin so that
+// DMLOG lines can be captured to disk on their own, independent of whether
+// debugDeepMind is echoing them to the main app logger too: turning
+// debugDeepMind off to keep DMLOG out of the main log does not also turn off
+// file capture.
+//
+// minLevel controls the lowest level written to the file sink; pass
+// zap.DebugLevel to capture everything, including DMLOG lines.
+func ToZapLogPluginWithFileSink(path string, rotate FileRotateConfig, minLevel zapcore.Level) ToZapLogPluginOption {
+	return toZapLogPluginOptionFunc(func(p *ToZapLogPlugin) {
+		writer := &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    rotate.MaxSizeMB,
+			MaxAge:     rotate.MaxAgeDays,
+			MaxBackups: rotate.MaxBackups,
+			Compress:   rotate.Compress,
+		}
+
+		encoderConfig := zap.NewProductionEncoderConfig()
+		var encoder zapcore.Encoder
+		switch p.fileSinkFormat {
+		case FormatJSON:
+			encoder = zapcore.NewJSONEncoder(encoderConfig)
+		default:
+			encoder = zapcore.NewConsoleEncoder(encoderConfig)
+		}
+
+		core := zapcore.NewCore(encoder, zapcore.AddSync(writer), minLevel)
+		p.fileLogger = zap.New(core)
+	})
+}
+
+// ToZapLogPluginWithFileSinkFormat selects the encoding (text or JSON) used by
+// a file sink installed through ToZapLogPluginWithFileSink. It must be applied
+// before ToZapLogPluginWithFileSink in the option list for it to take effect,
+// since the sink reads the format at construction time.
+func ToZapLogPluginWithFileSinkFormat(format ToZapLogPluginFormat) ToZapLogPluginOption {
+	return toZapLogPluginOptionFunc(func(p *ToZapLogPlugin) {
+		p.fileSinkFormat = format
+	})
+}
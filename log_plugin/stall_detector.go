@@ -0,0 +1,133 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logplugin
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// StallDetector wraps a LogPlugin and enforces a per-line read timeout: if no
+// line is seen within readTimeout of the previous one, it logs a warning and
+// invokes onStall so the caller (typically the mindreader supervisor) can
+// trigger a health-check downgrade or a node restart. It models the timeout
+// config used by other stream ingest daemons: a single duration set at
+// construction time, applied around every read, configurable per instance.
+type StallDetector struct {
+	next        LogPlugin
+	logger      *zap.Logger
+	readTimeout time.Duration
+	onStall     func(elapsed time.Duration)
+
+	mu            sync.Mutex
+	lastLineAt    time.Time
+	closeOnce     sync.Once
+	stopCh        chan struct{}
+	lastLineAgeGa prometheus.Gauge
+}
+
+// NewStallDetector creates a StallDetector in front of next. onStall may be
+// nil if the caller only cares about the warning log and the LastLineAge /
+// gauge accessors.
+//
+// If readTimeout is zero or negative, stall detection is disabled: LogLine
+// and Close still forward to next and LastLineAge still reports the age, but
+// no warning or onStall callback ever fires and the LastLineAgeGauge gauge
+// is never updated. This keeps a zero-value-by-accident readTimeout a no-op instead of
+// a NewTicker panic.
+func NewStallDetector(next LogPlugin, readTimeout time.Duration, onStall func(elapsed time.Duration), logger *zap.Logger) *StallDetector {
+	d := &StallDetector{
+		next:        next,
+		logger:      logger,
+		readTimeout: readTimeout,
+		onStall:     onStall,
+		lastLineAt:  time.Now(),
+		stopCh:      make(chan struct{}),
+		lastLineAgeGa: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "log_plugin_last_line_age_seconds",
+			Help: "Age, in seconds, of the most recently read log line.",
+		}),
+	}
+
+	if readTimeout > 0 {
+		go d.watch()
+	}
+	return d
+}
+
+// LastLineAgeGauge exposes the last-line-age gauge so a caller can register
+// it with its own prometheus.Registerer.
+func (d *StallDetector) LastLineAgeGauge() prometheus.Gauge {
+	return d.lastLineAgeGa
+}
+
+func (d *StallDetector) watch() {
+	ticker := time.NewTicker(d.readTimeout / 4)
+	defer ticker.Stop()
+
+	var warned bool
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-ticker.C:
+			elapsed := d.LastLineAge()
+			d.lastLineAgeGa.Set(elapsed.Seconds())
+
+			if elapsed < d.readTimeout {
+				warned = false
+				continue
+			}
+			if warned {
+				continue
+			}
+			warned = true
+
+			d.logger.Warn("no log line received within read timeout", zap.Duration("elapsed", elapsed), zap.Duration("read_timeout", d.readTimeout))
+			if d.onStall != nil {
+				d.onStall(elapsed)
+			}
+		}
+	}
+}
+
+// LastLineAge returns how long it has been since the last line was read.
+func (d *StallDetector) LastLineAge() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return time.Since(d.lastLineAt)
+}
+
+// LogLine implements LogPlugin: it records the line's arrival time, resetting
+// the stall clock, and forwards the line to the wrapped plugin.
+func (d *StallDetector) LogLine(in string) {
+	d.mu.Lock()
+	d.lastLineAt = time.Now()
+	d.mu.Unlock()
+
+	d.next.LogLine(in)
+}
+
+// Close implements LogPlugin: it stops the stall-watching goroutine and
+// forwards to the wrapped plugin. It is safe to call more than once.
+func (d *StallDetector) Close(err error) {
+	d.closeOnce.Do(func() {
+		close(d.stopCh)
+	})
+	d.next.Close(err)
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logplugin
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestStallDetectorForwardsLinesAndResetsAge(t *testing.T) {
+	next := &recordingLogPlugin{}
+	d := NewStallDetector(next, time.Hour, nil, zap.NewNop())
+	defer d.Close(nil)
+
+	d.LogLine("hello")
+
+	if len(next.lines) != 1 || next.lines[0] != "hello" {
+		t.Fatalf("expected line forwarded to wrapped plugin, got %v", next.lines)
+	}
+	if d.LastLineAge() > time.Second {
+		t.Fatalf("expected LastLineAge to be near zero right after LogLine, got %v", d.LastLineAge())
+	}
+}
+
+func TestStallDetectorInvokesOnStallAfterTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var stalled bool
+	var elapsed time.Duration
+
+	d := NewStallDetector(&recordingLogPlugin{}, 40*time.Millisecond, func(e time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		stalled = true
+		elapsed = e
+	}, zap.NewNop())
+	defer d.Close(nil)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		done := stalled
+		mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !stalled {
+		t.Fatal("expected OnStall to be invoked after the read timeout elapsed")
+	}
+	if elapsed < 40*time.Millisecond {
+		t.Fatalf("expected elapsed >= read timeout, got %v", elapsed)
+	}
+}
+
+func TestStallDetectorRecoversAfterNewLine(t *testing.T) {
+	d := NewStallDetector(&recordingLogPlugin{}, 30*time.Millisecond, nil, zap.NewNop())
+	defer d.Close(nil)
+
+	time.Sleep(60 * time.Millisecond) // let it go stale once
+	d.LogLine("still alive")
+
+	if d.LastLineAge() > 30*time.Millisecond {
+		t.Fatalf("expected LastLineAge to reset after a new line, got %v", d.LastLineAge())
+	}
+}
+
+func TestStallDetectorZeroReadTimeoutDisablesWatch(t *testing.T) {
+	next := &recordingLogPlugin{}
+	d := NewStallDetector(next, 0, func(time.Duration) {
+		t.Fatal("OnStall must never fire when readTimeout is disabled")
+	}, zap.NewNop())
+
+	time.Sleep(50 * time.Millisecond)
+	d.LogLine("line")
+	d.Close(nil)
+	d.Close(nil) // must not panic when called twice
+
+	if !next.closed {
+		t.Fatal("expected Close to forward to the wrapped plugin")
+	}
+}
@@ -53,6 +53,13 @@ type ToZapLogPlugin struct {
 	debugDeepMind bool
 
 	levelAdjustements map[*regexp.Regexp]zapcore.Level
+	fileSinkFormat    ToZapLogPluginFormat
+
+	// fileLogger, when set via ToZapLogPluginWithFileSink, receives every
+	// line independently of logger/debugDeepMind so file capture doesn't
+	// depend on what is echoed to the main app log. Lines adjusted to
+	// NoDisplay are dropped for both loggers.
+	fileLogger *zap.Logger
 }
 
 func NewToZapLogPlugin(debugDeepMind bool, logger *zap.Logger, options ...ToZapLogPluginOption) *ToZapLogPlugin {
@@ -80,6 +87,9 @@ func (p *ToZapLogPlugin) LogLine(in string) {
 		if p.debugDeepMind {
 			p.logger.Debug(in)
 		}
+		if p.fileLogger != nil {
+			p.fileLogger.Debug(in)
+		}
 
 		return
 	}
@@ -105,4 +115,7 @@ func (p *ToZapLogPlugin) LogLine(in string) {
 	}
 
 	p.logger.Check(level, in).Write()
+	if p.fileLogger != nil {
+		p.fileLogger.Check(level, in).Write()
+	}
 }
@@ -46,6 +46,8 @@ type ArchiverSelector struct {
 	logger  *zap.Logger
 }
 
+// NewArchiverSelector creates an ArchiverSelector backed by the given oneblock
+// and merge archivers.
 func NewArchiverSelector(
 	oneblockArchiver Archiver,
 	mergeArchiver Archiver,
@@ -0,0 +1,176 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mindreader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// S3TransportOptions configures the S3-compatible object store used by an
+// oneblock or merge archiver backed by object storage (AWS S3, MinIO, Ceph
+// RGW, ...).
+type S3TransportOptions struct {
+	EndpointOverride     string // non-empty to target a non-AWS endpoint, e.g. a MinIO instance
+	PathStyle            bool   // use path-style addressing instead of virtual-hosted style, required by most on-prem S3-compatible stores
+	Region               string
+	ServerSideEncryption string // e.g. "aws:kms" or "AES256", empty disables SSE
+	KMSKeyID             string // required when ServerSideEncryption is "aws:kms"
+}
+
+// NFSTransportOptions configures a mounted filesystem (NFS, VFS, local disk)
+// used as an archiver backend.
+type NFSTransportOptions struct {
+	MountRoot    string // root directory under which oneblock/merged files are written
+	FsyncOnWrite bool   // fsync each file before closing it, trading throughput for durability
+}
+
+// ArchiverTransport carries the network transport and backend-specific
+// options meant to be shared by the oneblock and merge archivers: building
+// one and passing it to whatever constructs those archivers lets every
+// upload path share retry semantics, connection pooling, and observability
+// instead of each archiver wiring its own http.Client.
+//
+// Neither oneblock_archiver.go nor merge_archiver.go exist in this tree, so
+// nothing here calls Do yet; callers of Do should build it into their own
+// Archiver implementation's upload path, and ArchiverSelector itself does not
+// construct or hold one.
+type ArchiverTransport struct {
+	// HTTPClient is used for every request made to the remote storage
+	// backend. Configure its Timeout, Transport (TLS config, proxy, max idle
+	// conns) as needed. If nil, NewArchiverTransport installs a client with
+	// sane defaults.
+	HTTPClient *http.Client
+
+	// MaxRetries is the number of additional attempts made after a failed
+	// request, with exponential backoff between attempts starting at
+	// RetryBaseDelay.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+
+	S3  S3TransportOptions
+	NFS NFSTransportOptions
+
+	requestDuration *prometheus.HistogramVec
+	retryCount      *prometheus.CounterVec
+}
+
+// NewArchiverTransport builds an ArchiverTransport, filling in an HTTPClient
+// with sane connection pooling defaults when none is supplied and wiring the
+// per-request duration histogram and retry counter used to observe every
+// upload path that shares this transport.
+func NewArchiverTransport(opts ArchiverTransport) *ArchiverTransport {
+	t := opts
+	if t.HTTPClient == nil {
+		t.HTTPClient = &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 20,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		}
+	}
+	if t.RetryBaseDelay == 0 {
+		t.RetryBaseDelay = 500 * time.Millisecond
+	}
+
+	t.requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "archiver_transport_request_duration_seconds",
+		Help: "Duration of requests made to the archiver storage backend, by backend and outcome.",
+	}, []string{"backend", "outcome"})
+
+	t.retryCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "archiver_transport_retry_count",
+		Help: "Number of retries performed against the archiver storage backend, by backend.",
+	}, []string{"backend"})
+
+	return &t
+}
+
+// RequestDurationHistogram exposes the per-request duration histogram so a
+// caller can register it with its own prometheus.Registerer.
+func (t *ArchiverTransport) RequestDurationHistogram() *prometheus.HistogramVec {
+	return t.requestDuration
+}
+
+// RetryCounter exposes the retry counter so a caller can register it with its
+// own prometheus.Registerer.
+func (t *ArchiverTransport) RetryCounter() *prometheus.CounterVec {
+	return t.retryCount
+}
+
+// Do executes req against backend (used only to label metrics, e.g. "s3" or
+// "nfs"), retrying up to MaxRetries times with exponential backoff on
+// transport-level errors or 5xx responses.
+//
+// A req with a body must have a non-nil GetBody (true for requests built with
+// NewRequest from a *bytes.Buffer, *bytes.Reader, or *strings.Reader body);
+// a req with a nil Body, such as a GET/HEAD request, needs none. Since
+// http.Client.Do reads and closes req.Body on the first attempt, every retry
+// needs a fresh body reader, which is what GetBody is for. Reusing req.Body
+// across attempts would silently upload an empty or truncated body on retry.
+func (t *ArchiverTransport) Do(backend string, req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		return nil, fmt.Errorf("archiver transport: request for %s has a body but no GetBody, cannot safely retry", req.URL)
+	}
+
+	delay := t.RetryBaseDelay
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt <= t.MaxRetries; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, fmt.Errorf("archiver transport: rebuilding request body for retry: %w", bodyErr)
+			}
+			req.Body = body
+		}
+
+		start := time.Now()
+		resp, err = t.HTTPClient.Do(req)
+		outcome := "success"
+		if err != nil || (resp != nil && resp.StatusCode >= 500) {
+			outcome = "error"
+		}
+		t.requestDuration.WithLabelValues(backend, outcome).Observe(time.Since(start).Seconds())
+
+		if err == nil && (resp == nil || resp.StatusCode < 500) {
+			return resp, nil
+		}
+
+		if attempt == t.MaxRetries {
+			break
+		}
+
+		if resp != nil {
+			// Drain and close so the connection is returned to the pool
+			// instead of leaking out of it on every retry.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		t.retryCount.WithLabelValues(backend).Inc()
+		time.Sleep(delay)
+		delay *= 2
+	}
+
+	return resp, err
+}
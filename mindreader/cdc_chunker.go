@@ -0,0 +1,110 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mindreader
+
+import (
+	"crypto/sha256"
+)
+
+// Chunk boundary parameters for the rolling Rabin-fingerprint chunker used by
+// the delta-bundle format (see delta_bundle.go). These mirror common CDC
+// defaults: a 64-byte rolling window, a ~16KiB target chunk, bounded to
+// [4KiB, 64KiB] so a single byte change never produces a pathologically tiny
+// or pathologically large chunk.
+const (
+	cdcWindowSize = 64
+	cdcMinChunk   = 4 * 1024
+	cdcMaxChunk   = 64 * 1024
+	cdcAvgChunk   = 16 * 1024
+	cdcMask       = cdcAvgChunk - 1  // cdcAvgChunk is a power of two
+	cdcPolynomial = 0x3DA3358B4DC173 // arbitrary odd 64-bit constant used as the Rabin polynomial
+)
+
+// cdcChunk identifies one content-defined chunk within the payload it was cut
+// from.
+type cdcChunk struct {
+	SHA256 [32]byte
+	Offset int
+	Len    int
+}
+
+// chunkPayload splits payload into content-defined chunks using a rolling
+// Rabin fingerprint over a fixed cdcWindowSize-byte window, cutting a chunk
+// boundary whenever the fingerprint's low bits selected by cdcMask are all
+// zero, subject to
+// the cdcMinChunk/cdcMaxChunk bounds. The same payload always produces the
+// same chunk boundaries, which is what lets a delta bundle reference chunks
+// from an earlier base bundle by content hash instead of by position.
+func chunkPayload(payload []byte) []cdcChunk {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	// leadingCoeff is cdcPolynomial^cdcWindowSize, the weight of the byte
+	// that is about to slide out of the window; subtracting
+	// outgoing*leadingCoeff before folding in the incoming byte keeps
+	// fingerprint a function of exactly the last cdcWindowSize bytes seen
+	// since the last cut, instead of every byte since the last cut.
+	leadingCoeff := uint64(1)
+	for i := 0; i < cdcWindowSize; i++ {
+		leadingCoeff *= cdcPolynomial
+	}
+
+	var chunks []cdcChunk
+	start := 0
+
+	var window [cdcWindowSize]byte
+	var windowPos, filled int
+	var fingerprint uint64
+
+	for i := 0; i < len(payload); i++ {
+		var outgoing byte
+		if filled == cdcWindowSize {
+			outgoing = window[windowPos]
+		}
+
+		fingerprint = fingerprint*cdcPolynomial + uint64(payload[i]) - uint64(outgoing)*leadingCoeff
+
+		window[windowPos] = payload[i]
+		windowPos = (windowPos + 1) % cdcWindowSize
+		if filled < cdcWindowSize {
+			filled++
+		}
+
+		length := i - start + 1
+		atBoundary := length >= cdcMinChunk && filled == cdcWindowSize && (fingerprint&cdcMask) == 0
+		if atBoundary || length >= cdcMaxChunk {
+			chunks = append(chunks, newCDCChunk(payload[start:i+1], start))
+			start = i + 1
+			fingerprint = 0
+			filled = 0
+			windowPos = 0
+		}
+	}
+
+	if start < len(payload) {
+		chunks = append(chunks, newCDCChunk(payload[start:], start))
+	}
+
+	return chunks
+}
+
+func newCDCChunk(data []byte, offset int) cdcChunk {
+	return cdcChunk{
+		SHA256: sha256.Sum256(data),
+		Offset: offset,
+		Len:    len(data),
+	}
+}
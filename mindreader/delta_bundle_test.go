@@ -0,0 +1,129 @@
+// Copyright 2019 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mindreader
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func randomPayload(seed int64, size int) []byte {
+	r := rand.New(rand.NewSource(seed))
+	buf := make([]byte, size)
+	r.Read(buf)
+	return buf
+}
+
+func TestChunkPayloadDeterministic(t *testing.T) {
+	payload := randomPayload(1, 200*1024)
+
+	first := chunkPayload(payload)
+	second := chunkPayload(payload)
+
+	if len(first) != len(second) {
+		t.Fatalf("chunk count differs across runs: %d vs %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("chunk %d differs across runs: %+v vs %+v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestChunkPayloadBounds(t *testing.T) {
+	payload := randomPayload(2, 500*1024)
+	chunks := chunkPayload(payload)
+
+	if len(chunks) == 0 {
+		t.Fatal("expected at least one chunk")
+	}
+
+	total := 0
+	for i, c := range chunks {
+		total += c.Len
+		isLast := i == len(chunks)-1
+		if c.Len < cdcMinChunk && !isLast {
+			t.Errorf("chunk %d has length %d, below cdcMinChunk %d", i, c.Len, cdcMinChunk)
+		}
+		if c.Len > cdcMaxChunk {
+			t.Errorf("chunk %d has length %d, above cdcMaxChunk %d", i, c.Len, cdcMaxChunk)
+		}
+	}
+	if total != len(payload) {
+		t.Fatalf("chunks cover %d bytes, payload is %d bytes", total, len(payload))
+	}
+}
+
+func TestChunkPayloadStableAroundEdit(t *testing.T) {
+	base := randomPayload(3, 300*1024)
+	edited := append([]byte(nil), base...)
+	// Flip a handful of bytes in the middle of the payload; a content-defined
+	// chunker should leave chunks far from the edit untouched.
+	for i := 150 * 1024; i < 150*1024+8; i++ {
+		edited[i] ^= 0xFF
+	}
+
+	baseChunks := chunkPayload(base)
+	editedChunks := chunkPayload(edited)
+
+	baseHashes := make(map[[32]byte]bool, len(baseChunks))
+	for _, c := range baseChunks {
+		baseHashes[c.SHA256] = true
+	}
+
+	shared := 0
+	for _, c := range editedChunks {
+		if baseHashes[c.SHA256] {
+			shared++
+		}
+	}
+	if shared == 0 {
+		t.Fatal("expected at least some chunks to survive an unrelated edit elsewhere in the payload")
+	}
+}
+
+func TestDeltaBundleRoundTrip(t *testing.T) {
+	basePayload := randomPayload(4, 400*1024)
+	basePayload, baseIndex := newBaseBundle(basePayload)
+
+	deltaPayload := append([]byte(nil), basePayload...)
+	for i := 100 * 1024; i < 100*1024+4096; i++ {
+		deltaPayload[i] ^= 0xFF
+	}
+	deltaPayload = append(deltaPayload, randomPayload(5, 8*1024)...)
+
+	refs := buildDeltaBundle(deltaPayload, baseIndex)
+
+	var sawLiteral bool
+	for _, ref := range refs {
+		if ref.Literal != nil {
+			sawLiteral = true
+			break
+		}
+	}
+	if !sawLiteral {
+		t.Fatal("expected the delta bundle to contain at least one literal chunk for the changed/appended bytes")
+	}
+
+	materialized, err := materializeDeltaBundle(refs, baseIndex, basePayload)
+	if err != nil {
+		t.Fatalf("materializeDeltaBundle: %v", err)
+	}
+
+	if !bytes.Equal(materialized, deltaPayload) {
+		t.Fatalf("materialized payload does not round-trip: got %d bytes, want %d bytes", len(materialized), len(deltaPayload))
+	}
+}